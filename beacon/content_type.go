@@ -0,0 +1,104 @@
+package beacon
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/zen-eth/shisui/storage"
+	"github.com/zen-eth/shisui/types/beacon"
+)
+
+// Verifier lets a content type plug in its own validation - BLS signature
+// checks, fork-consistency checks, and so on - without PortalLightApi's
+// fetch path needing to know anything about them. It is called with the
+// already-decoded value; a nil Verifier means "accept as decoded."
+type Verifier func(spec *common.Spec, value common.SpecObj) error
+
+// decodableValue is implemented by each Forked* content-value wrapper:
+// it knows how to deserialize itself and how to unwrap to the
+// underlying common.SpecObj.
+type decodableValue interface {
+	beacon.ContentValue
+	Deserialize(spec *common.Spec, dr *codec.DecodingReader) error
+}
+
+// ContentType enumerates the beacon content types PortalLightApi serves
+// as single values (as opposed to LightClientUpdateRange, which returns
+// many and is fetched separately by getUpdatesWindow). It is the key
+// into the contentTypes dispatch table and into PortalLightApi.verifiers.
+type ContentType byte
+
+const (
+	ContentTypeBootstrap        ContentType = ContentType(LightClientBootstrap)
+	ContentTypeUpdate           ContentType = ContentType(LightClientUpdate)
+	ContentTypeFinalityUpdate   ContentType = ContentType(LightClientFinalityUpdate)
+	ContentTypeOptimisticUpdate ContentType = ContentType(LightClientOptimisticUpdate)
+)
+
+// contentTypeEntry bundles everything getByType needs to fetch and
+// decode one content type: the wire selector byte and a constructor for
+// a fresh decode target. The verifier is looked up separately, from
+// PortalLightApi.verifiers, since it's registered per-instance (see
+// PortalLightApi.SetVerifier) rather than fixed at table-definition
+// time.
+type contentTypeEntry struct {
+	selector byte
+	newValue func() decodableValue
+}
+
+// contentTypes is the single dispatch table mapping each ContentType to
+// its selector and value codec. PortalLightApi's single-value getters
+// (GetBootstrap, GetFinalityUpdate, GetOptimisticUpdate) all go through
+// getByType against this table instead of each repeating its own
+// selector/newValue boilerplate.
+var contentTypes = map[ContentType]contentTypeEntry{
+	ContentTypeBootstrap: {
+		selector: LightClientBootstrap,
+		newValue: func() decodableValue { return &beacon.ForkedLightClientBootstrap{} },
+	},
+	ContentTypeUpdate: {
+		selector: LightClientUpdate,
+		newValue: func() decodableValue { return &beacon.ForkedLightClientUpdate{} },
+	},
+	ContentTypeFinalityUpdate: {
+		selector: LightClientFinalityUpdate,
+		newValue: func() decodableValue { return &beacon.ForkedLightClientFinalityUpdate{} },
+	},
+	ContentTypeOptimisticUpdate: {
+		selector: LightClientOptimisticUpdate,
+		newValue: func() decodableValue { return &beacon.ForkedLightClientOptimisticUpdate{} },
+	},
+}
+
+// getByType fetches the content behind key for ct, decodes it per
+// contentTypes[ct], runs it through ct's registered Verifier (if any),
+// and returns the unwrapped value.
+func getByType(p *PortalLightApi, ct ContentType, key interface{ MarshalSSZ() ([]byte, error) }) (common.SpecObj, error) {
+	entry, ok := contentTypes[ct]
+	if !ok {
+		return nil, fmt.Errorf("unregistered content type %d", ct)
+	}
+	contentKeyBytes, err := key.MarshalSSZ()
+	if err != nil {
+		return nil, err
+	}
+	contentKey := storage.NewContentKey(entry.selector, contentKeyBytes).Encode()
+	contentId := p.portalProtocol.ToContentId(contentKey)
+	data, err := p.getContent(contentKey, contentId)
+	if err != nil {
+		return nil, err
+	}
+	value := entry.newValue()
+	if err := value.Deserialize(p.spec, codec.NewDecodingReader(bytes.NewReader(data), uint64(len(data)))); err != nil {
+		return nil, err
+	}
+	obj := value.Unwrap()
+	if verify := p.verifiers[ct]; verify != nil {
+		if err := verify(p.spec, obj); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
@@ -0,0 +1,604 @@
+package beacon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/bellatrix"
+	"github.com/protolambda/zrnt/eth2/beacon/capella"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/deneb"
+	"github.com/protolambda/zrnt/eth2/util/bls"
+	"github.com/protolambda/ztyp/tree"
+)
+
+// MinSyncCommitteeParticipants is the spec's absolute floor on sync
+// committee participation (MIN_SYNC_COMMITTEE_PARTICIPANTS = 1) for an
+// update to be considered at all. It is deliberately not the half-
+// committee SAFETY_THRESHOLD: that only bears on whether an optimistic
+// update is preferred relative to max-seen participation, not on basic
+// update validity, and applying it as a hard floor here would reject
+// every legitimately low-but-nonzero-participation update.
+const MinSyncCommitteeParticipants = 1
+
+var (
+	ErrInvalidCheckpoint     = errors.New("bootstrap root does not match checkpoint")
+	ErrInsufficientSignature = errors.New("update does not have sufficient sync committee participation")
+	ErrInvalidSignature      = errors.New("sync committee signature verification failed")
+	ErrNoNextSyncCommittee   = errors.New("store has no next sync committee for the requested period")
+)
+
+// update is the set of fields the sync loop and fork-choice rules need out
+// of a LightClientUpdate/FinalityUpdate/OptimisticUpdate, independent of
+// which fork (Altair/Bellatrix/Capella/Deneb) produced it.
+type update struct {
+	AttestedHeader    common.SpecObj
+	AttestedSlot      common.Slot
+	FinalizedHeader   common.SpecObj
+	FinalizedSlot     common.Slot
+	NextSyncCommittee *altair.SyncCommittee
+	SyncAggregate     *altair.SyncAggregate
+	SignatureSlot     common.Slot
+}
+
+// LightClientStore mirrors the store defined by the Altair light-client
+// sync protocol: the latest finalized and optimistic headers, and the
+// sync committees needed to verify updates as they arrive.
+type LightClientStore struct {
+	FinalizedHeader               common.SpecObj
+	CurrentSyncCommittee          *altair.SyncCommittee
+	NextSyncCommittee             *altair.SyncCommittee
+	OptimisticHeader              common.SpecObj
+	PreviousMaxActiveParticipants uint64
+	CurrentMaxActiveParticipants  uint64
+}
+
+// LightClient drives a Helios/Selene-style sync loop against a ConsensusAPI,
+// maintaining a LightClientStore and publishing newly verified headers.
+type LightClient struct {
+	api  ConsensusAPI
+	spec *common.Spec
+
+	checkpoint common.Root
+
+	mu    sync.RWMutex
+	store *LightClientStore
+
+	headers chan common.SpecObj
+
+	pollInterval time.Duration
+}
+
+// NewLightClient creates a LightClient that will bootstrap from checkpoint
+// once Start is called. pollInterval controls how often finality/optimistic
+// updates are polled once the store is synced to head.
+func NewLightClient(api ConsensusAPI, spec *common.Spec, checkpoint common.Root, pollInterval time.Duration) *LightClient {
+	// If the ConsensusAPI is a *PortalLightApi, register a Verifier for
+	// every content type the sync loop consumes so a value that decodes
+	// into a fork the loop doesn't yet understand is rejected at the
+	// fetch boundary, with a clear error, instead of failing deep inside
+	// asUpdate.
+	if portalApi, ok := api.(*PortalLightApi); ok {
+		portalApi.SetVerifier(ContentTypeBootstrap, verifyKnownForkedHeader)
+		portalApi.SetVerifier(ContentTypeUpdate, verifyKnownForkedHeader)
+		portalApi.SetVerifier(ContentTypeFinalityUpdate, verifyKnownForkedHeader)
+		portalApi.SetVerifier(ContentTypeOptimisticUpdate, verifyKnownForkedHeader)
+	}
+
+	return &LightClient{
+		api:          api,
+		spec:         spec,
+		checkpoint:   checkpoint,
+		headers:      make(chan common.SpecObj, 16),
+		pollInterval: pollInterval,
+	}
+}
+
+// verifyKnownForkedHeader rejects content that decoded successfully but
+// into a fork the sync loop has no handling for, so that happens at the
+// fetch boundary with a clear error rather than as a generic type
+// assertion failure deep inside asUpdate/Bootstrap.
+func verifyKnownForkedHeader(spec *common.Spec, value common.SpecObj) error {
+	switch value.(type) {
+	case *altair.LightClientBootstrap, *bellatrix.LightClientBootstrap, *capella.LightClientBootstrap, *deneb.LightClientBootstrap,
+		*altair.LightClientUpdate, *bellatrix.LightClientUpdate, *capella.LightClientUpdate, *deneb.LightClientUpdate,
+		*altair.LightClientFinalityUpdate, *bellatrix.LightClientFinalityUpdate, *capella.LightClientFinalityUpdate, *deneb.LightClientFinalityUpdate,
+		*altair.LightClientOptimisticUpdate, *bellatrix.LightClientOptimisticUpdate, *capella.LightClientOptimisticUpdate, *deneb.LightClientOptimisticUpdate:
+		return nil
+	default:
+		return fmt.Errorf("unsupported light client content type %T", value)
+	}
+}
+
+// Bootstrap fetches a LightClientBootstrap for the configured checkpoint
+// and initializes the store from it. It fails if the bootstrap's header
+// does not hash to the checkpoint root.
+func (lc *LightClient) Bootstrap() error {
+	obj, err := lc.api.GetBootstrap(lc.checkpoint)
+	if err != nil {
+		return fmt.Errorf("fetching bootstrap: %w", err)
+	}
+
+	var header common.SpecObj
+	var committee *altair.SyncCommittee
+	switch b := obj.(type) {
+	case *altair.LightClientBootstrap:
+		header, committee = &b.Header, &b.CurrentSyncCommittee
+	case *bellatrix.LightClientBootstrap:
+		header, committee = &b.Header, &b.CurrentSyncCommittee
+	case *capella.LightClientBootstrap:
+		header, committee = &b.Header, &b.CurrentSyncCommittee
+	case *deneb.LightClientBootstrap:
+		header, committee = &b.Header, &b.CurrentSyncCommittee
+	default:
+		return fmt.Errorf("unexpected bootstrap type %T", obj)
+	}
+
+	root, err := beaconRoot(lc.spec, header)
+	if err != nil {
+		return err
+	}
+	if root != lc.checkpoint {
+		return ErrInvalidCheckpoint
+	}
+
+	lc.mu.Lock()
+	lc.store = &LightClientStore{
+		FinalizedHeader:      header,
+		CurrentSyncCommittee: committee,
+		OptimisticHeader:     header,
+	}
+	lc.mu.Unlock()
+	return nil
+}
+
+// Rebootstrap discards the current store and re-bootstraps from a new
+// checkpoint. This is used when GetBootstrap succeeds but the returned
+// header doesn't match what we expected, or when following a fork
+// transition the store can no longer be advanced from.
+func (lc *LightClient) Rebootstrap(checkpoint common.Root) error {
+	lc.mu.Lock()
+	lc.checkpoint = checkpoint
+	lc.store = nil
+	lc.mu.Unlock()
+	return lc.Bootstrap()
+}
+
+// Start bootstraps the store (if not already done), walks sync committee
+// periods forward via GetUpdates, then polls finality/optimistic updates
+// every pollInterval until ctx is cancelled.
+func (lc *LightClient) Start(ctx context.Context) error {
+	lc.mu.RLock()
+	synced := lc.store != nil
+	lc.mu.RUnlock()
+	if !synced {
+		if err := lc.Bootstrap(); err != nil {
+			return err
+		}
+	}
+
+	if err := lc.syncToHead(ctx); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(lc.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := lc.advanceHead(); err != nil {
+				// A single failed fetch or a momentarily
+				// low-participation update shouldn't permanently stop
+				// the light client: log it and keep polling rather
+				// than tearing the sync loop down.
+				log.Warn("light client poll failed", "err", err)
+			}
+		}
+	}
+}
+
+// syncToHead walks forward one sync committee period at a time,
+// starting from the store's finalized period, until GetUpdates stops
+// returning new updates. The period counter is advanced locally rather
+// than re-derived from lc.currentPeriod() each iteration: the best
+// update for period N is itself finalized within period N, so applying
+// it sets NextSyncCommittee without crossing a period boundary, and
+// currentPeriod() would otherwise never move past N.
+func (lc *LightClient) syncToHead(ctx context.Context) error {
+	for period := lc.currentPeriod(); ; period++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		objs, err := lc.api.GetUpdates(period, 1)
+		if err != nil {
+			return fmt.Errorf("fetching updates from period %d: %w", period, err)
+		}
+		if len(objs) == 0 {
+			return nil
+		}
+		for _, obj := range objs {
+			u, err := asUpdate(obj)
+			if err != nil {
+				return err
+			}
+			if err := lc.applyUpdate(u); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// advanceHead polls the finality and optimistic update endpoints and
+// applies whichever one moves the store forward.
+func (lc *LightClient) advanceHead() error {
+	finObj, err := lc.api.GetFinalityUpdate()
+	if err != nil {
+		return fmt.Errorf("fetching finality update: %w", err)
+	}
+	finUpdate, err := asUpdate(finObj)
+	if err != nil {
+		return err
+	}
+	if err := lc.applyUpdate(finUpdate); err != nil {
+		return err
+	}
+
+	optObj, err := lc.api.GetOptimisticUpdate()
+	if err != nil {
+		return fmt.Errorf("fetching optimistic update: %w", err)
+	}
+	optUpdate, err := asUpdate(optObj)
+	if err != nil {
+		return err
+	}
+	return lc.applyOptimisticUpdate(optUpdate)
+}
+
+// applyUpdate runs the Altair light-client fork-choice rules against a
+// normalized update: verify sufficient participation, verify the sync
+// committee signature, and only replace the next sync committee once the
+// update is justified.
+func (lc *LightClient) applyUpdate(u *update) error {
+	participants := countParticipants(u.SyncAggregate)
+	if participants < MinSyncCommitteeParticipants {
+		return ErrInsufficientSignature
+	}
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if lc.store == nil {
+		return errors.New("store not bootstrapped")
+	}
+
+	committee := lc.committeeForSignature(u.SignatureSlot)
+	if committee == nil {
+		return ErrNoNextSyncCommittee
+	}
+	attestedRoot, err := beaconRoot(lc.spec, u.AttestedHeader)
+	if err != nil {
+		return err
+	}
+	if err := verifySyncCommitteeSignature(lc.spec, committee, u.SyncAggregate, attestedRoot, u.SignatureSlot); err != nil {
+		return err
+	}
+
+	lc.trackParticipation(participants)
+
+	// Only adopt a new next sync committee from an update signed by a
+	// supermajority (at least 2/3) of the sync committee, matching the
+	// spec's "only replace next sync committee when justified" rule.
+	// Whether the update carries a finalized header is not checked here.
+	if isSupermajority(participants) && !isEmptyCommittee(u.NextSyncCommittee) {
+		lc.store.NextSyncCommittee = u.NextSyncCommittee
+	}
+
+	if u.FinalizedHeader != nil {
+		finalizedSlot, err := slotOf(lc.store.FinalizedHeader)
+		if err != nil {
+			return err
+		}
+		if u.FinalizedSlot > finalizedSlot {
+			// Only rotate the sync committees once this update crosses a
+			// sync-committee-period boundary (update_finalized_period ==
+			// store_period + 1 in the spec); a finalized header that
+			// merely advances within the same period must not discard
+			// the current committee.
+			crossedPeriod := lc.periodOf(u.FinalizedSlot) > lc.periodOf(finalizedSlot)
+			lc.store.FinalizedHeader = u.FinalizedHeader
+			if crossedPeriod && lc.store.NextSyncCommittee != nil {
+				lc.store.CurrentSyncCommittee = lc.store.NextSyncCommittee
+				lc.store.NextSyncCommittee = nil
+			}
+			lc.publish(lc.store.FinalizedHeader)
+		}
+	}
+	return nil
+}
+
+func (lc *LightClient) applyOptimisticUpdate(u *update) error {
+	participants := countParticipants(u.SyncAggregate)
+	if participants < MinSyncCommitteeParticipants {
+		return ErrInsufficientSignature
+	}
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if lc.store == nil {
+		return errors.New("store not bootstrapped")
+	}
+	committee := lc.committeeForSignature(u.SignatureSlot)
+	if committee == nil {
+		return ErrNoNextSyncCommittee
+	}
+	attestedRoot, err := beaconRoot(lc.spec, u.AttestedHeader)
+	if err != nil {
+		return err
+	}
+	if err := verifySyncCommitteeSignature(lc.spec, committee, u.SyncAggregate, attestedRoot, u.SignatureSlot); err != nil {
+		return err
+	}
+	optimisticSlot, err := slotOf(lc.store.OptimisticHeader)
+	if err != nil {
+		return err
+	}
+	if u.AttestedSlot > optimisticSlot {
+		lc.store.OptimisticHeader = u.AttestedHeader
+		lc.publish(lc.store.OptimisticHeader)
+	}
+	return nil
+}
+
+// committeeForSignature picks the current or next sync committee
+// depending on which period the update's signature slot falls in, per
+// the spec's compute_sync_committee_period_at_slot(signature_slot).
+// Must be called with lc.mu held.
+func (lc *LightClient) committeeForSignature(signatureSlot common.Slot) *altair.SyncCommittee {
+	if lc.periodOf(signatureSlot) == lc.currentPeriod() {
+		return lc.store.CurrentSyncCommittee
+	}
+	return lc.store.NextSyncCommittee
+}
+
+func (lc *LightClient) currentPeriod() uint64 {
+	if lc.store == nil || lc.store.FinalizedHeader == nil {
+		return 0
+	}
+	slot, err := slotOf(lc.store.FinalizedHeader)
+	if err != nil {
+		return 0
+	}
+	return lc.periodOf(slot)
+}
+
+// periodOf returns the sync committee period slot falls in.
+func (lc *LightClient) periodOf(slot common.Slot) uint64 {
+	epoch := lc.spec.SlotToEpoch(slot)
+	return uint64(epoch) / uint64(common.EPOCHS_PER_SYNC_COMMITTEE_PERIOD)
+}
+
+func (lc *LightClient) trackParticipation(participants uint64) {
+	lc.store.PreviousMaxActiveParticipants = lc.store.CurrentMaxActiveParticipants
+	if participants > lc.store.CurrentMaxActiveParticipants {
+		lc.store.CurrentMaxActiveParticipants = participants
+	}
+}
+
+func (lc *LightClient) publish(header common.SpecObj) {
+	select {
+	case lc.headers <- header:
+	default:
+		// Slow consumer: drop rather than block the sync loop.
+	}
+}
+
+// FinalizedHeader returns the most recently verified finalized header.
+func (lc *LightClient) FinalizedHeader() common.SpecObj {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	if lc.store == nil {
+		return nil
+	}
+	return lc.store.FinalizedHeader
+}
+
+// OptimisticHeader returns the most recently verified optimistic header.
+func (lc *LightClient) OptimisticHeader() common.SpecObj {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	if lc.store == nil {
+		return nil
+	}
+	return lc.store.OptimisticHeader
+}
+
+// Headers returns a channel of newly verified headers (finalized or
+// optimistic) for downstream consumers such as an execution-layer RPC.
+func (lc *LightClient) Headers() <-chan common.SpecObj {
+	return lc.headers
+}
+
+// asUpdate normalizes a LightClientUpdate/FinalityUpdate/OptimisticUpdate
+// of any known fork into the fields the sync loop needs.
+func asUpdate(obj common.SpecObj) (*update, error) {
+	switch o := obj.(type) {
+	case *altair.LightClientUpdate:
+		return updateFrom(&o.AttestedHeader, &o.FinalizedHeader, o.NextSyncCommittee, &o.SyncAggregate, o.SignatureSlot)
+	case *bellatrix.LightClientUpdate:
+		return updateFrom(&o.AttestedHeader, &o.FinalizedHeader, o.NextSyncCommittee, &o.SyncAggregate, o.SignatureSlot)
+	case *capella.LightClientUpdate:
+		return updateFrom(&o.AttestedHeader, &o.FinalizedHeader, o.NextSyncCommittee, &o.SyncAggregate, o.SignatureSlot)
+	case *deneb.LightClientUpdate:
+		return updateFrom(&o.AttestedHeader, &o.FinalizedHeader, o.NextSyncCommittee, &o.SyncAggregate, o.SignatureSlot)
+	case *altair.LightClientFinalityUpdate:
+		return updateFrom(&o.AttestedHeader, &o.FinalizedHeader, altair.SyncCommittee{}, &o.SyncAggregate, o.SignatureSlot)
+	case *bellatrix.LightClientFinalityUpdate:
+		return updateFrom(&o.AttestedHeader, &o.FinalizedHeader, altair.SyncCommittee{}, &o.SyncAggregate, o.SignatureSlot)
+	case *capella.LightClientFinalityUpdate:
+		return updateFrom(&o.AttestedHeader, &o.FinalizedHeader, altair.SyncCommittee{}, &o.SyncAggregate, o.SignatureSlot)
+	case *deneb.LightClientFinalityUpdate:
+		return updateFrom(&o.AttestedHeader, &o.FinalizedHeader, altair.SyncCommittee{}, &o.SyncAggregate, o.SignatureSlot)
+	case *altair.LightClientOptimisticUpdate:
+		return updateFrom(&o.AttestedHeader, nil, altair.SyncCommittee{}, &o.SyncAggregate, o.SignatureSlot)
+	case *bellatrix.LightClientOptimisticUpdate:
+		return updateFrom(&o.AttestedHeader, nil, altair.SyncCommittee{}, &o.SyncAggregate, o.SignatureSlot)
+	case *capella.LightClientOptimisticUpdate:
+		return updateFrom(&o.AttestedHeader, nil, altair.SyncCommittee{}, &o.SyncAggregate, o.SignatureSlot)
+	case *deneb.LightClientOptimisticUpdate:
+		return updateFrom(&o.AttestedHeader, nil, altair.SyncCommittee{}, &o.SyncAggregate, o.SignatureSlot)
+	default:
+		return nil, fmt.Errorf("unexpected update type %T", obj)
+	}
+}
+
+func updateFrom(attested common.SpecObj, finalized common.SpecObj, nextCommittee altair.SyncCommittee, agg *altair.SyncAggregate, sigSlot common.Slot) (*update, error) {
+	attestedSlot, err := slotOf(attested)
+	if err != nil {
+		return nil, err
+	}
+	u := &update{
+		AttestedHeader: attested,
+		AttestedSlot:   attestedSlot,
+		SyncAggregate:  agg,
+		SignatureSlot:  sigSlot,
+	}
+	if !isEmptyCommittee(&nextCommittee) {
+		committee := nextCommittee
+		u.NextSyncCommittee = &committee
+	}
+	if finalized != nil {
+		finalizedSlot, err := slotOf(finalized)
+		if err != nil {
+			return nil, err
+		}
+		if finalizedSlot > 0 {
+			u.FinalizedHeader = finalized
+			u.FinalizedSlot = finalizedSlot
+		}
+	}
+	return u, nil
+}
+
+// beaconRoot returns the hash-tree-root of the beacon block header
+// embedded in a light-client header of any known fork.
+func beaconRoot(spec *common.Spec, header common.SpecObj) (common.Root, error) {
+	beacon, err := beaconHeader(header)
+	if err != nil {
+		return common.Root{}, err
+	}
+	return beacon.HashTreeRoot(tree.GetHashFn()), nil
+}
+
+func slotOf(header common.SpecObj) (common.Slot, error) {
+	beacon, err := beaconHeader(header)
+	if err != nil {
+		return 0, err
+	}
+	return beacon.Slot, nil
+}
+
+func beaconHeader(header common.SpecObj) (*common.BeaconBlockHeader, error) {
+	switch h := header.(type) {
+	case *altair.LightClientHeader:
+		return &h.Beacon, nil
+	case *bellatrix.LightClientHeader:
+		return &h.Beacon, nil
+	case *capella.LightClientHeader:
+		return &h.Beacon, nil
+	case *deneb.LightClientHeader:
+		return &h.Beacon, nil
+	default:
+		return nil, fmt.Errorf("unsupported light client header type %T", header)
+	}
+}
+
+// ExecutionBlockHash returns the execution-payload block hash carried by a
+// post-merge light-client header, and false for Phase0/Altair headers
+// which predate the merge and carry no execution payload.
+func ExecutionBlockHash(header common.SpecObj) (common.Root, bool) {
+	switch h := header.(type) {
+	case *bellatrix.LightClientHeader:
+		return h.Execution.BlockHash, true
+	case *capella.LightClientHeader:
+		return h.Execution.BlockHash, true
+	case *deneb.LightClientHeader:
+		return h.Execution.BlockHash, true
+	default:
+		return common.Root{}, false
+	}
+}
+
+// ExecutionStateRoot returns the execution-payload state root carried by a
+// post-merge light-client header, and false otherwise.
+func ExecutionStateRoot(header common.SpecObj) (common.Root, bool) {
+	switch h := header.(type) {
+	case *bellatrix.LightClientHeader:
+		return h.Execution.StateRoot, true
+	case *capella.LightClientHeader:
+		return h.Execution.StateRoot, true
+	case *deneb.LightClientHeader:
+		return h.Execution.StateRoot, true
+	default:
+		return common.Root{}, false
+	}
+}
+
+// ExecutionReceiptsRoot returns the execution-payload receipts root
+// carried by a post-merge light-client header, and false otherwise.
+func ExecutionReceiptsRoot(header common.SpecObj) (common.Root, bool) {
+	switch h := header.(type) {
+	case *bellatrix.LightClientHeader:
+		return h.Execution.ReceiptsRoot, true
+	case *capella.LightClientHeader:
+		return h.Execution.ReceiptsRoot, true
+	case *deneb.LightClientHeader:
+		return h.Execution.ReceiptsRoot, true
+	default:
+		return common.Root{}, false
+	}
+}
+
+func countParticipants(agg *altair.SyncAggregate) uint64 {
+	var count uint64
+	for i := uint64(0); i < uint64(common.SYNC_COMMITTEE_SIZE); i++ {
+		if agg.SyncCommitteeBits.GetBit(i) {
+			count++
+		}
+	}
+	return count
+}
+
+func isSupermajority(participants uint64) bool {
+	return participants*3 >= uint64(common.SYNC_COMMITTEE_SIZE)*2
+}
+
+func isEmptyCommittee(committee *altair.SyncCommittee) bool {
+	return committee == nil || committee.AggregatePubkey == (common.BLSPubkey{})
+}
+
+func verifySyncCommitteeSignature(spec *common.Spec, committee *altair.SyncCommittee, agg *altair.SyncAggregate, attestedRoot common.Root, signatureSlot common.Slot) error {
+	if committee == nil {
+		return ErrNoNextSyncCommittee
+	}
+	pubkeys := make([]common.BLSPubkey, 0, common.SYNC_COMMITTEE_SIZE)
+	for i := uint64(0); i < uint64(common.SYNC_COMMITTEE_SIZE); i++ {
+		if agg.SyncCommitteeBits.GetBit(i) {
+			pubkeys = append(pubkeys, committee.Pubkeys[i])
+		}
+	}
+	domain := common.ComputeDomain(common.DOMAIN_SYNC_COMMITTEE, spec.ForkVersion(signatureSlot), spec.GenesisValidatorsRoot)
+	signingRoot := common.ComputeSigningRoot(attestedRoot, domain)
+	if !bls.FastAggregateVerify(pubkeys, signingRoot[:], agg.SyncCommitteeSignature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -3,6 +3,8 @@ package beacon
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/protolambda/zrnt/eth2/beacon/common"
@@ -15,6 +17,19 @@ import (
 
 const GenesisTime uint64 = 1606824023
 
+// Per the Altair light-client p2p-interface: a single
+// LightClientUpdatesByRange request must not span more than
+// MaxRequestLightClientUpdates periods, and each decoded update must not
+// exceed MaxLightClientUpdateSize.
+const (
+	MaxRequestLightClientUpdates = 128
+	MaxLightClientUpdateSize     = 1 << 20 // ~1 MiB
+
+	// getUpdatesConcurrency bounds how many period windows are looked up
+	// over the network at once.
+	getUpdatesConcurrency = 4
+)
+
 type ConsensusAPI interface {
 	GetBootstrap(blockRoot common.Root) (common.SpecObj, error)
 	GetUpdates(firstPeriod, count uint64) ([]common.SpecObj, error)
@@ -29,15 +44,51 @@ var _ ConsensusAPI = &PortalLightApi{}
 type PortalLightApi struct {
 	portalProtocol *portalwire.PortalProtocol
 	spec           *common.Spec
+
+	// verifiers holds an optional Verifier per ContentType, the same key
+	// the contentTypes dispatch table uses. Consumers such as
+	// beacon.LightClient use SetVerifier to plug in BLS signature /
+	// fork-consistency checks without this type needing to know about
+	// them.
+	verifiers map[ContentType]Verifier
 }
 
 func NewPortalLightApi(p *portalwire.PortalProtocol, spec *common.Spec) *PortalLightApi {
+	p.SetOfferSizeGuard(LightClientUpdateSizeGuard)
 	return &PortalLightApi{
 		portalProtocol: p,
 		spec:           spec,
+		verifiers:      make(map[ContentType]Verifier),
 	}
 }
 
+// LightClientUpdateSizeGuard rejects an inbound offer for a
+// LightClientUpdate content key whose range spans more periods than
+// MaxRequestLightClientUpdates, the most a single
+// LightClientUpdatesByRange request may legitimately span per the
+// Altair light-client p2p-interface. It is installed as the
+// PortalProtocol's offer size guard so such offers are declined with
+// GenericDeclined at accept time instead of being accepted and then
+// failing - or being truncated - once we actually try to transfer them.
+func LightClientUpdateSizeGuard(contentKey []byte) bool {
+	if len(contentKey) == 0 || contentKey[0] != LightClientUpdate {
+		return false
+	}
+	var key beacon.LightClientUpdateKey
+	if err := key.UnmarshalSSZ(contentKey[1:]); err != nil {
+		// Malformed key: not this guard's concern, let the normal
+		// content-key handling surface the error.
+		return false
+	}
+	return key.Count > MaxRequestLightClientUpdates
+}
+
+// SetVerifier registers verify to run on every value fetched for ct,
+// replacing any previously registered Verifier for it.
+func (p *PortalLightApi) SetVerifier(ct ContentType, verify Verifier) {
+	p.verifiers[ct] = verify
+}
+
 // ChainID implements ConsensusAPI.
 func (p *PortalLightApi) ChainID() uint64 {
 	return 1
@@ -48,23 +99,7 @@ func (p *PortalLightApi) GetBootstrap(blockRoot tree.Root) (common.SpecObj, erro
 	bootstrapKey := &beacon.LightClientBootstrapKey{
 		BlockHash: blockRoot[:],
 	}
-	contentKeyBytes, err := bootstrapKey.MarshalSSZ()
-	if err != nil {
-		return nil, err
-	}
-	contentKey := storage.NewContentKey(LightClientBootstrap, contentKeyBytes).Encode()
-	// Get from local
-	contentId := p.portalProtocol.ToContentId(contentKey)
-	res, err := p.getContent(contentKey, contentId)
-	if err != nil {
-		return nil, err
-	}
-	forkedLightClientBootstrap := &beacon.ForkedLightClientBootstrap{}
-	err = forkedLightClientBootstrap.Deserialize(p.spec, codec.NewDecodingReader(bytes.NewReader(res), uint64(len(res))))
-	if err != nil {
-		return nil, err
-	}
-	return forkedLightClientBootstrap.Bootstrap, nil
+	return getByType(p, ContentTypeBootstrap, bootstrapKey)
 }
 
 // GetFinalityUpdate implements ConsensusAPI.
@@ -75,23 +110,7 @@ func (p *PortalLightApi) GetFinalityUpdate() (common.SpecObj, error) {
 	finUpdateKey := &beacon.LightClientFinalityUpdateKey{
 		FinalizedSlot: 0,
 	}
-	contentKeyBytes, err := finUpdateKey.MarshalSSZ()
-	if err != nil {
-		return nil, err
-	}
-	contentKey := storage.NewContentKey(LightClientFinalityUpdate, contentKeyBytes).Encode()
-	// Get from local
-	contentId := p.portalProtocol.ToContentId(contentKey)
-	res, err := p.getContent(contentKey, contentId)
-	if err != nil {
-		return nil, err
-	}
-	finalityUpdate := &beacon.ForkedLightClientFinalityUpdate{}
-	err = finalityUpdate.Deserialize(p.spec, codec.NewDecodingReader(bytes.NewReader(res), uint64(len(res))))
-	if err != nil {
-		return nil, err
-	}
-	return finalityUpdate.LightClientFinalityUpdate, nil
+	return getByType(p, ContentTypeFinalityUpdate, finUpdateKey)
 }
 
 // GetOptimisticUpdate implements ConsensusAPI.
@@ -100,27 +119,74 @@ func (p *PortalLightApi) GetOptimisticUpdate() (common.SpecObj, error) {
 	optimisticUpdateKey := &beacon.LightClientOptimisticUpdateKey{
 		OptimisticSlot: uint64(currentSlot),
 	}
-	contentKeyBytes, err := optimisticUpdateKey.MarshalSSZ()
-	if err != nil {
-		return nil, err
-	}
-	contentKey := storage.NewContentKey(LightClientOptimisticUpdate, contentKeyBytes).Encode()
-	// Get from local
-	contentId := p.portalProtocol.ToContentId(contentKey)
-	res, err := p.getContent(contentKey, contentId)
-	if err != nil {
-		return nil, err
+	return getByType(p, ContentTypeOptimisticUpdate, optimisticUpdateKey)
+}
+
+// GetUpdates implements ConsensusAPI. It splits [firstPeriod, firstPeriod+count)
+// into MaxRequestLightClientUpdates-period windows, since that's the most
+// a single LightClientUpdatesByRange content key may span, looks the
+// windows up concurrently, and stitches the results back into a single
+// ordered slice.
+func (p *PortalLightApi) GetUpdates(firstPeriod uint64, count uint64) ([]common.SpecObj, error) {
+	windows := splitIntoWindows(firstPeriod, count, MaxRequestLightClientUpdates)
+
+	results := make([][]common.SpecObj, len(windows))
+	errs := make([]error, len(windows))
+
+	sem := make(chan struct{}, getUpdatesConcurrency)
+	var wg sync.WaitGroup
+	for i, w := range windows {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, w updateWindow) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], errs[i] = p.getUpdatesWindow(w.start, w.count)
+		}(i, w)
+	}
+	wg.Wait()
+
+	var res []common.SpecObj
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("fetching updates window [%d, %d): %w", windows[i].start, windows[i].start+windows[i].count, err)
+		}
+		res = append(res, results[i]...)
 	}
-	optimisticUpdate := &beacon.ForkedLightClientOptimisticUpdate{}
-	err = optimisticUpdate.Deserialize(p.spec, codec.NewDecodingReader(bytes.NewReader(res), uint64(len(res))))
-	if err != nil {
-		return nil, err
+	return res, nil
+}
+
+// updateWindow is a single sub-request within the spec's per-request
+// update cap.
+type updateWindow struct {
+	start uint64
+	count uint64
+}
+
+// splitIntoWindows breaks [start, start+count) into consecutive windows
+// of at most maxWindow periods each.
+func splitIntoWindows(start, count, maxWindow uint64) []updateWindow {
+	if count == 0 {
+		return nil
+	}
+	windows := make([]updateWindow, 0, (count+maxWindow-1)/maxWindow)
+	for remaining, period := count, start; remaining > 0; {
+		n := remaining
+		if n > maxWindow {
+			n = maxWindow
+		}
+		windows = append(windows, updateWindow{start: period, count: n})
+		period += n
+		remaining -= n
 	}
-	return optimisticUpdate.LightClientOptimisticUpdate, nil
+	return windows
 }
 
-// GetUpdates implements ConsensusAPI.
-func (p *PortalLightApi) GetUpdates(firstPeriod uint64, count uint64) ([]common.SpecObj, error) {
+// getUpdatesWindow fetches and validates a single LightClientUpdatesByRange
+// window. Before decoding, it rejects the window if its encoded content is
+// larger than MaxLightClientUpdateSize bytes per requested period; the
+// size of each individual decoded update is not checked.
+func (p *PortalLightApi) getUpdatesWindow(firstPeriod, count uint64) ([]common.SpecObj, error) {
 	lightClientUpdateKey := &beacon.LightClientUpdateKey{
 		StartPeriod: firstPeriod,
 		Count:       count,
@@ -136,15 +202,21 @@ func (p *PortalLightApi) GetUpdates(firstPeriod uint64, count uint64) ([]common.
 	if err != nil {
 		return nil, err
 	}
+	if len(data) > MaxLightClientUpdateSize*int(count) {
+		return nil, fmt.Errorf("updates window [%d, %d) exceeds the aggregate size cap: %d bytes", firstPeriod, firstPeriod+count, len(data))
+	}
 	var lightClientUpdateRange beacon.LightClientUpdateRange = make([]beacon.ForkedLightClientUpdate, 0)
 	err = lightClientUpdateRange.Deserialize(p.spec, codec.NewDecodingReader(bytes.NewReader(data), uint64(len(data))))
 	if err != nil {
 		return nil, err
 	}
-	res := make([]common.SpecObj, len(lightClientUpdateRange))
-
-	for i, item := range lightClientUpdateRange {
-		res[i] = item.LightClientUpdate
+	res := lightClientUpdateRange.UnwrapAll()
+	if verify := p.verifiers[ContentTypeUpdate]; verify != nil {
+		for _, obj := range res {
+			if err := verify(p.spec, obj); err != nil {
+				return nil, err
+			}
+		}
 	}
 	return res, nil
 }
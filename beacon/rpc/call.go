@@ -0,0 +1,290 @@
+package rpc
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// CallMsg mirrors ethereum.CallMsg: the eth_call parameters that select
+// the code path and inputs to execute.
+type CallMsg struct {
+	From     common.Address
+	To       *common.Address
+	Gas      uint64
+	GasPrice *big.Int
+	Value    *big.Int
+	Data     []byte
+}
+
+// Call implements eth_call: it executes msg against a read-only,
+// portal-backed view of the state at the beacon-verified header for tag.
+// Every account the EVM touches is fetched from the state network and
+// verified against the header's state root on first access, then kept
+// for the rest of the call; a storage slot the call has not written is
+// fetched and verified against its account's storage root on each read.
+// Nothing is persisted afterwards.
+func (e *ExecAPI) Call(msg CallMsg, tag BlockTag) ([]byte, error) {
+	header, err := e.resolveHeader(tag)
+	if err != nil {
+		return nil, err
+	}
+	if msg.To == nil {
+		return nil, errors.New("eth_call against contract creation is not supported")
+	}
+
+	db := newPortalStateDB(e, header.Root)
+	blockCtx := vm.BlockContext{
+		CanTransfer: func(vm.StateDB, common.Address, *uint256.Int) bool { return true },
+		Transfer:    func(vm.StateDB, common.Address, common.Address, *uint256.Int) {},
+		GetHash:     func(uint64) common.Hash { return common.Hash{} },
+		Coinbase:    header.Coinbase,
+		BlockNumber: header.Number,
+		Time:        header.Time,
+		Difficulty:  header.Difficulty,
+		GasLimit:    header.GasLimit,
+		BaseFee:     header.BaseFee,
+	}
+	txCtx := vm.TxContext{
+		Origin:   msg.From,
+		GasPrice: msg.GasPrice,
+	}
+	evm := vm.NewEVM(blockCtx, db, params.MainnetChainConfig, vm.Config{NoBaseFee: true})
+	evm.SetTxContext(txCtx)
+
+	gas := msg.Gas
+	if gas == 0 {
+		gas = header.GasLimit
+	}
+	value := msg.Value
+	if value == nil {
+		value = big.NewInt(0)
+	}
+	ret, _, err := evm.Call(msg.From, *msg.To, msg.Data, gas, uint256.MustFromBig(value))
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// portalStateDB is a read-only, lazily-populated vm.StateDB backed by
+// state-network proofs verified against a single fixed state root. It
+// overlays in-EVM mutations (balance/nonce/storage writes, snapshots) in
+// memory for the duration of a single call; nothing is written back.
+type portalStateDB struct {
+	api       *ExecAPI
+	stateRoot common.Hash
+
+	accounts map[common.Address]*types.StateAccount
+	code     map[common.Hash][]byte
+	storage  map[common.Address]map[common.Hash]common.Hash
+
+	refund uint64
+	logs   []*types.Log
+	snaps  []snapshot
+}
+
+func newPortalStateDB(api *ExecAPI, stateRoot common.Hash) *portalStateDB {
+	return &portalStateDB{
+		api:       api,
+		stateRoot: stateRoot,
+		accounts:  make(map[common.Address]*types.StateAccount),
+		code:      make(map[common.Hash][]byte),
+		storage:   make(map[common.Address]map[common.Hash]common.Hash),
+	}
+}
+
+func (s *portalStateDB) account(addr common.Address) *types.StateAccount {
+	if acc, ok := s.accounts[addr]; ok {
+		return acc
+	}
+	acc, err := s.api.verifiedAccount(s.stateRoot, addr)
+	if err != nil {
+		// vm.StateDB has no error return on reads; surface an empty
+		// account rather than panicking mid-execution.
+		acc = &types.StateAccount{Root: types.EmptyRootHash}
+	}
+	s.accounts[addr] = acc
+	return acc
+}
+
+func (s *portalStateDB) CreateAccount(addr common.Address) {
+	s.accounts[addr] = &types.StateAccount{Root: types.EmptyRootHash}
+}
+
+func (s *portalStateDB) CreateContract(common.Address) {}
+
+func (s *portalStateDB) SubBalance(addr common.Address, amount *uint256.Int, _ tracing.BalanceChangeReason) {
+	acc := s.account(addr)
+	bal := acc.Balance.ToBig()
+	bal.Sub(bal, amount.ToBig())
+	acc.Balance, _ = uint256.FromBig(bal)
+}
+
+func (s *portalStateDB) AddBalance(addr common.Address, amount *uint256.Int, _ tracing.BalanceChangeReason) {
+	acc := s.account(addr)
+	bal := acc.Balance.ToBig()
+	bal.Add(bal, amount.ToBig())
+	acc.Balance, _ = uint256.FromBig(bal)
+}
+
+func (s *portalStateDB) GetBalance(addr common.Address) *uint256.Int {
+	acc := s.account(addr)
+	if acc.Balance == nil {
+		return uint256.NewInt(0)
+	}
+	return acc.Balance
+}
+
+func (s *portalStateDB) GetNonce(addr common.Address) uint64 {
+	return s.account(addr).Nonce
+}
+
+func (s *portalStateDB) SetNonce(addr common.Address, nonce uint64) {
+	s.account(addr).Nonce = nonce
+}
+
+func (s *portalStateDB) GetCodeHash(addr common.Address) common.Hash {
+	return common.BytesToHash(s.account(addr).CodeHash)
+}
+
+func (s *portalStateDB) GetCode(addr common.Address) []byte {
+	codeHash := s.GetCodeHash(addr)
+	if codeHash == (common.Hash{}) || codeHash == types.EmptyCodeHash {
+		return nil
+	}
+	if code, ok := s.code[codeHash]; ok {
+		return code
+	}
+	code, err := s.api.verifiedCode(codeHash)
+	if err != nil {
+		return nil
+	}
+	s.code[codeHash] = code
+	return code
+}
+
+func (s *portalStateDB) SetCode(addr common.Address, code []byte) {
+	hash := crypto.Keccak256Hash(code)
+	s.code[hash] = code
+	s.account(addr).CodeHash = hash[:]
+}
+
+func (s *portalStateDB) GetCodeSize(addr common.Address) int {
+	return len(s.GetCode(addr))
+}
+
+func (s *portalStateDB) AddRefund(gas uint64)      { s.refund += gas }
+func (s *portalStateDB) SubRefund(gas uint64)      { s.refund -= gas }
+func (s *portalStateDB) GetRefund() uint64         { return s.refund }
+func (s *portalStateDB) GetTransientState(common.Address, common.Hash) common.Hash {
+	return common.Hash{}
+}
+func (s *portalStateDB) SetTransientState(common.Address, common.Hash, common.Hash) {}
+
+func (s *portalStateDB) slots(addr common.Address) map[common.Hash]common.Hash {
+	slots, ok := s.storage[addr]
+	if !ok {
+		slots = make(map[common.Hash]common.Hash)
+		s.storage[addr] = slots
+	}
+	return slots
+}
+
+func (s *portalStateDB) GetCommittedState(addr common.Address, key common.Hash) common.Hash {
+	acc := s.account(addr)
+	value, err := s.api.verifiedStorage(acc.Root, addr, key)
+	if err != nil {
+		return common.Hash{}
+	}
+	return value
+}
+
+func (s *portalStateDB) GetState(addr common.Address, key common.Hash) common.Hash {
+	if value, ok := s.slots(addr)[key]; ok {
+		return value
+	}
+	return s.GetCommittedState(addr, key)
+}
+
+func (s *portalStateDB) SetState(addr common.Address, key, value common.Hash) {
+	s.slots(addr)[key] = value
+}
+
+func (s *portalStateDB) GetStorageRoot(addr common.Address) common.Hash {
+	return s.account(addr).Root
+}
+
+func (s *portalStateDB) SelfDestruct(common.Address)            {}
+func (s *portalStateDB) HasSelfDestructed(common.Address) bool  { return false }
+func (s *portalStateDB) Selfdestruct6780(common.Address)        {}
+
+func (s *portalStateDB) Exist(addr common.Address) bool {
+	acc := s.account(addr)
+	return acc.Nonce != 0 || (acc.Balance != nil && !acc.Balance.IsZero()) || len(acc.CodeHash) != 0
+}
+
+func (s *portalStateDB) Empty(addr common.Address) bool {
+	return !s.Exist(addr)
+}
+
+func (s *portalStateDB) AddressInAccessList(common.Address) bool { return true }
+func (s *portalStateDB) SlotInAccessList(common.Address, common.Hash) (bool, bool) {
+	return true, true
+}
+func (s *portalStateDB) AddAddressToAccessList(common.Address)          {}
+func (s *portalStateDB) AddSlotToAccessList(common.Address, common.Hash) {}
+func (s *portalStateDB) Prepare(params.Rules, common.Address, common.Address, *common.Address, []common.Address, types.AccessList) {
+}
+
+// snapshot is a point-in-time copy of the mutable overlay, since reads
+// fall back to the verified state and never change underneath a call.
+type snapshot struct {
+	accounts map[common.Address]types.StateAccount
+	storage  map[common.Address]map[common.Hash]common.Hash
+	refund   uint64
+}
+
+func (s *portalStateDB) Snapshot() int {
+	snap := snapshot{
+		accounts: make(map[common.Address]types.StateAccount, len(s.accounts)),
+		storage:  make(map[common.Address]map[common.Hash]common.Hash, len(s.storage)),
+		refund:   s.refund,
+	}
+	for addr, acc := range s.accounts {
+		snap.accounts[addr] = *acc
+	}
+	for addr, slots := range s.storage {
+		copied := make(map[common.Hash]common.Hash, len(slots))
+		for k, v := range slots {
+			copied[k] = v
+		}
+		snap.storage[addr] = copied
+	}
+	s.snaps = append(s.snaps, snap)
+	return len(s.snaps) - 1
+}
+
+func (s *portalStateDB) RevertToSnapshot(id int) {
+	if id < 0 || id >= len(s.snaps) {
+		return
+	}
+	snap := s.snaps[id]
+	for addr, acc := range snap.accounts {
+		a := acc
+		s.accounts[addr] = &a
+	}
+	s.storage = snap.storage
+	s.refund = snap.refund
+	s.snaps = s.snaps[:id]
+}
+
+func (s *portalStateDB) AddLog(log *types.Log) { s.logs = append(s.logs, log) }
+func (s *portalStateDB) AddPreimage(common.Hash, []byte) {}
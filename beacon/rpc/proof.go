@@ -0,0 +1,139 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// verifiedAccount fetches the state-network proof for address at
+// stateRoot and verifies it against the trie root before returning the
+// decoded account.
+func (e *ExecAPI) verifiedAccount(stateRoot common.Hash, address common.Address) (*types.StateAccount, error) {
+	contentKey := e.AccountProofKey(stateRoot, address.Hash())
+	contentId := e.state.ToContentId(contentKey)
+	proofRLP, _, err := e.state.ContentLookup(contentKey, contentId)
+	if err != nil {
+		return nil, fmt.Errorf("looking up account proof for %s: %w", address, err)
+	}
+	var proof [][]byte
+	if err := rlp.DecodeBytes(proofRLP, &proof); err != nil {
+		return nil, fmt.Errorf("decoding account proof for %s: %w", address, err)
+	}
+	key := crypto.Keccak256(address[:])
+	value, err := trie.VerifyProof(stateRoot, key, newProofDB(proof))
+	if err != nil {
+		return nil, fmt.Errorf("verifying account proof for %s against state root %s: %w", address, stateRoot, err)
+	}
+	if value == nil {
+		// Non-existence proof: the account has never been touched.
+		return &types.StateAccount{Root: types.EmptyRootHash}, nil
+	}
+	var account types.StateAccount
+	if err := rlp.DecodeBytes(value, &account); err != nil {
+		return nil, fmt.Errorf("decoding verified account for %s: %w", address, err)
+	}
+	return &account, nil
+}
+
+// verifiedStorage fetches the state-network proof for a storage slot
+// under accountRoot (the account's own storage trie root) and verifies
+// it before returning the slot's value.
+func (e *ExecAPI) verifiedStorage(accountRoot common.Hash, address common.Address, slot common.Hash) (common.Hash, error) {
+	contentKey := e.StorageProofKey(accountRoot, address.Hash(), slot)
+	contentId := e.state.ToContentId(contentKey)
+	proofRLP, _, err := e.state.ContentLookup(contentKey, contentId)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("looking up storage proof for %s[%s]: %w", address, slot, err)
+	}
+	var proof [][]byte
+	if err := rlp.DecodeBytes(proofRLP, &proof); err != nil {
+		return common.Hash{}, fmt.Errorf("decoding storage proof for %s[%s]: %w", address, slot, err)
+	}
+	key := crypto.Keccak256(slot[:])
+	value, err := trie.VerifyProof(accountRoot, key, newProofDB(proof))
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("verifying storage proof for %s[%s] against root %s: %w", address, slot, accountRoot, err)
+	}
+	if value == nil {
+		return common.Hash{}, nil
+	}
+	var decoded []byte
+	if err := rlp.DecodeBytes(value, &decoded); err != nil {
+		return common.Hash{}, fmt.Errorf("decoding verified storage value: %w", err)
+	}
+	return common.BytesToHash(decoded), nil
+}
+
+// verifiedCode fetches contract bytecode by its hash, via the history
+// network's bytecode content type, and verifies it hashes to codeHash
+// before returning it.
+func (e *ExecAPI) verifiedCode(codeHash common.Hash) ([]byte, error) {
+	contentKey := e.CodeKey(codeHash)
+	contentId := e.history.ToContentId(contentKey)
+	code, _, err := e.history.ContentLookup(contentKey, contentId)
+	if err != nil {
+		return nil, fmt.Errorf("looking up code %s: %w", codeHash, err)
+	}
+	if crypto.Keccak256Hash(code) != codeHash {
+		return nil, fmt.Errorf("code %s does not match requested hash", codeHash)
+	}
+	return code, nil
+}
+
+// verifiedReceipts fetches the full receipts list for header's block from
+// the history network and verifies it against header.ReceiptHash before
+// returning it. The history network serves receipts as the complete
+// per-block list rather than individual Merkle proofs, so verification
+// means recomputing the receipts trie root locally and comparing it
+// against the already-trusted header, the same role trie.VerifyProof
+// plays for the account/storage proofs above.
+func (e *ExecAPI) verifiedReceipts(header *types.Header) (types.Receipts, error) {
+	contentKey := e.ReceiptsKey(header.Hash())
+	contentId := e.history.ToContentId(contentKey)
+	data, _, err := e.history.ContentLookup(contentKey, contentId)
+	if err != nil {
+		return nil, fmt.Errorf("looking up receipts for block %s: %w", header.Hash(), err)
+	}
+	var receipts types.Receipts
+	if err := rlp.DecodeBytes(data, &receipts); err != nil {
+		return nil, fmt.Errorf("decoding receipts for block %s: %w", header.Hash(), err)
+	}
+	if root := types.DeriveSha(receipts, trie.NewStackTrie(nil)); root != header.ReceiptHash {
+		return nil, fmt.Errorf("receipts for block %s do not match header receipt root %s", header.Hash(), header.ReceiptHash)
+	}
+	return receipts, nil
+}
+
+// proofDB adapts an ordered slice of trie nodes, as returned over the
+// wire by the state network, to the ethdb.KeyValueReader interface
+// trie.VerifyProof expects: each node is keyed by its own hash.
+type proofDB struct {
+	nodes map[common.Hash][]byte
+}
+
+func newProofDB(proof [][]byte) *proofDB {
+	db := &proofDB{nodes: make(map[common.Hash][]byte, len(proof))}
+	for _, node := range proof {
+		db.nodes[crypto.Keccak256Hash(node)] = node
+	}
+	return db
+}
+
+func (db *proofDB) Has(key []byte) (bool, error) {
+	_, ok := db.nodes[common.BytesToHash(key)]
+	return ok, nil
+}
+
+func (db *proofDB) Get(key []byte) ([]byte, error) {
+	node, ok := db.nodes[common.BytesToHash(key)]
+	if !ok {
+		return nil, errors.New("proof node not found")
+	}
+	return node, nil
+}
@@ -0,0 +1,183 @@
+package portalwire
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// Clock is the monotonic clock PortalProtocol uses for all cache expiry.
+// It is satisfied by mclock.System (real time) and by mclock.Simulated,
+// which lets tests advance time deterministically instead of depending
+// on time.Now().
+type Clock = mclock.Clock
+
+const (
+	// defaultVersionsCacheTTL bounds how long a negotiated protocol
+	// version is trusted before being re-checked even if the node's ENR
+	// sequence hasn't changed.
+	defaultVersionsCacheTTL = 10 * time.Minute
+
+	// defaultTransferDeadline matches the uTP transfer timeout: past
+	// this, an InboundTransferInProgress marker is assumed stale rather
+	// than a genuinely stuck transfer.
+	defaultTransferDeadline = 30 * time.Second
+)
+
+// versionEntry is what versionsCache stores per node: the negotiated
+// protocol version, the ENR sequence it was negotiated against, and when
+// it should be re-checked regardless.
+type versionEntry struct {
+	version uint8
+	enrSeq  uint64
+	expiry  mclock.AbsTime
+}
+
+// versionsCache remembers the highest protocol version negotiated with
+// each node, re-checking it whenever the node's ENR sequence advances
+// (it may have upgraded) or after its TTL has elapsed.
+type versionsCache struct {
+	clock Clock
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	entries map[enode.ID]versionEntry
+}
+
+func newVersionsCache(clock Clock, ttl time.Duration) *versionsCache {
+	if ttl == 0 {
+		ttl = defaultVersionsCacheTTL
+	}
+	return &versionsCache{
+		clock:   clock,
+		ttl:     ttl,
+		entries: make(map[enode.ID]versionEntry),
+	}
+}
+
+// Get returns the cached version for node, provided its ENR sequence has
+// not advanced and the entry hasn't expired.
+func (c *versionsCache) Get(node *enode.Node) (uint8, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[node.ID()]
+	if !ok {
+		return 0, false
+	}
+	if entry.enrSeq != node.Seq() || c.clock.Now() >= entry.expiry {
+		delete(c.entries, node.ID())
+		return 0, false
+	}
+	return entry.version, true
+}
+
+// Set caches version for node until ttl elapses or node's ENR sequence
+// changes. A ttl of 0 uses the cache's configured default TTL.
+func (c *versionsCache) Set(node *enode.Node, version uint8, ttl time.Duration) {
+	if ttl == 0 {
+		ttl = c.ttl
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[node.ID()] = versionEntry{
+		version: version,
+		enrSeq:  node.Seq(),
+		expiry:  c.clock.Now().Add(ttl),
+	}
+}
+
+// transferringKeyCache tracks content keys for which an inbound uTP
+// transfer is currently in flight, so a second offer for the same key
+// can be flagged InboundTransferInProgress. Entries auto-expire after
+// the uTP transfer deadline so a stuck or abandoned transfer doesn't
+// permanently wedge a content_id.
+type transferringKeyCache struct {
+	clock    Clock
+	deadline time.Duration
+
+	mu      sync.Mutex
+	entries map[string]mclock.AbsTime // key -> expiry
+}
+
+// clockOrDefault returns p's Clock, defaulting it to mclock.System{}
+// (the real monotonic system clock) the first time it's needed so callers don't
+// have to wire one up unless they specifically want deterministic time.
+func (p *PortalProtocol) clockOrDefault() Clock {
+	if p.clock == nil {
+		p.clock = mclock.System{}
+	}
+	return p.clock
+}
+
+// SetClock overrides the Clock used for the versions and
+// transferring-key caches' expiry - an mclock.Simulated, say, so tests
+// can advance time deterministically instead of depending on
+// time.Now(). Call it before either cache is first used: both latch
+// their clock in lazily on first access.
+func (p *PortalProtocol) SetClock(clock Clock) {
+	p.clock = clock
+}
+
+// versionsCacheOrInit returns p's versions cache, creating it against
+// p's Clock on first use.
+func (p *PortalProtocol) versionsCacheOrInit() *versionsCache {
+	if p.versionsCache == nil {
+		p.versionsCache = newVersionsCache(p.clockOrDefault(), defaultVersionsCacheTTL)
+	}
+	return p.versionsCache
+}
+
+// transferringKeyCacheOrInit returns p's transferring-key cache,
+// creating it against p's Clock on first use.
+func (p *PortalProtocol) transferringKeyCacheOrInit() *transferringKeyCache {
+	if p.transferringKeyCache == nil {
+		p.transferringKeyCache = newTransferringKeyCache(p.clockOrDefault(), defaultTransferDeadline)
+	}
+	return p.transferringKeyCache
+}
+
+func newTransferringKeyCache(clock Clock, deadline time.Duration) *transferringKeyCache {
+	if deadline == 0 {
+		deadline = defaultTransferDeadline
+	}
+	return &transferringKeyCache{
+		clock:    clock,
+		deadline: deadline,
+		entries:  make(map[string]mclock.AbsTime),
+	}
+}
+
+// Has reports whether contentKey has an in-flight transfer recorded
+// against it that hasn't hit its deadline yet.
+func (c *transferringKeyCache) Has(contentKey []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := string(contentKey)
+	expiry, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	if c.clock.Now() >= expiry {
+		delete(c.entries, key)
+		return false
+	}
+	return true
+}
+
+// Set records contentKey as having an in-flight transfer, expiring
+// after the uTP transfer deadline.
+func (c *transferringKeyCache) Set(contentKey []byte, _ []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[string(contentKey)] = c.clock.Now().Add(c.deadline)
+}
+
+// Del clears contentKey's in-flight transfer marker, called once the
+// transfer actually completes (or fails) rather than waiting it out.
+func (c *transferringKeyCache) Del(contentKey []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, string(contentKey))
+}
@@ -0,0 +1,261 @@
+package portalwire
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+const (
+	// initialBackoff is the first backoff applied to a peer after a
+	// RateLimited response; it doubles on each consecutive RateLimited
+	// response, up to maxBackoff.
+	initialBackoff = time.Second
+	maxBackoff      = time.Minute * 5
+
+	// inboundTransferRetry is how long we wait before offering a
+	// content_id back to a peer that reported
+	// InboundTransferInProgress for it.
+	inboundTransferRetry = time.Second * 5
+
+	// outOfRadiusTTL bounds how long a NotWithinRadius suppression
+	// lasts: past this, the content id is offered to the peer again, so
+	// a peer that has since widened its radius stops being suppressed
+	// for it rather than being pinned out forever.
+	outOfRadiusTTL = time.Minute * 10
+
+	// tokenBucketCapacity and tokenBucketRefillInterval bound how many
+	// offers we send a single peer. Both are fixed: a peer's
+	// Accepted/RateLimited responses do not change its bucket.
+	tokenBucketCapacity      = 16
+	tokenBucketRefillInterval = time.Second
+)
+
+// OfferPolicy lets callers (gossip, neighborhood-gossip, the bridge) share
+// the same per-peer accounting built from AcceptV1 reason codes while
+// plugging in their own notion of "should I even try."
+type OfferPolicy interface {
+	// ShouldOffer reports whether contentId should be included in an
+	// offer to node, given everything learned about node so far.
+	ShouldOffer(node *enode.Node, contentId []byte) bool
+	// OnResult records the AcceptCode node returned for contentId.
+	OnResult(node *enode.Node, contentId []byte, code AcceptCode)
+}
+
+var _ OfferPolicy = (*OfferScheduler)(nil)
+
+// OfferScheduler turns the granular AcceptV1 reason codes into adaptive
+// offer behavior: exponential backoff per node on RateLimited, short
+// per-node-per-content retry on InboundTransferInProgress, per-node
+// suppression of AlreadyStored for the rest of the current gossip cycle,
+// a per-node-per-content suppression on NotWithinRadius that expires
+// after outOfRadiusTTL so a peer that has since widened its radius isn't
+// pinned out forever, and a fixed-size token-bucket cap per node whose
+// capacity and refill rate do not adapt to the peer's responses.
+type OfferScheduler struct {
+	mu sync.Mutex
+
+	backoffUntil    map[enode.ID]time.Time
+	backoffDuration map[enode.ID]time.Duration
+
+	retryAfter map[enode.ID]map[string]time.Time // node -> content id -> InboundTransferInProgress retry deadline
+
+	alreadyStored map[enode.ID]map[string]struct{} // node -> content ids rejected AlreadyStored, cleared by StartCycle
+
+	outOfRadius map[enode.ID]map[string]time.Time // node -> content id -> NotWithinRadius suppression deadline
+
+	buckets map[enode.ID]*tokenBucket
+
+	metrics schedulerMetrics
+}
+
+type schedulerMetrics struct {
+	accepted                  metrics.Counter
+	genericDeclined           metrics.Counter
+	alreadyStored             metrics.Counter
+	notWithinRadius           metrics.Counter
+	rateLimited               metrics.Counter
+	inboundTransferInProgress metrics.Counter
+}
+
+func newSchedulerMetrics() schedulerMetrics {
+	return schedulerMetrics{
+		accepted:                  metrics.NewRegisteredCounter("portalwire/offer/accepted", nil),
+		genericDeclined:           metrics.NewRegisteredCounter("portalwire/offer/generic_declined", nil),
+		alreadyStored:             metrics.NewRegisteredCounter("portalwire/offer/already_stored", nil),
+		notWithinRadius:           metrics.NewRegisteredCounter("portalwire/offer/not_within_radius", nil),
+		rateLimited:               metrics.NewRegisteredCounter("portalwire/offer/rate_limited", nil),
+		inboundTransferInProgress: metrics.NewRegisteredCounter("portalwire/offer/inbound_transfer_in_progress", nil),
+	}
+}
+
+// NewOfferScheduler creates an OfferScheduler with empty per-peer state.
+func NewOfferScheduler() *OfferScheduler {
+	return &OfferScheduler{
+		backoffUntil:    make(map[enode.ID]time.Time),
+		backoffDuration: make(map[enode.ID]time.Duration),
+		retryAfter:      make(map[enode.ID]map[string]time.Time),
+		alreadyStored:   make(map[enode.ID]map[string]struct{}),
+		outOfRadius:     make(map[enode.ID]map[string]time.Time),
+		buckets:         make(map[enode.ID]*tokenBucket),
+		metrics:         newSchedulerMetrics(),
+	}
+}
+
+// StartCycle clears the "already stored this cycle" suppression so that a
+// new gossip/bridge pass can re-offer content the peer may have since
+// evicted.
+func (s *OfferScheduler) StartCycle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alreadyStored = make(map[enode.ID]map[string]struct{})
+}
+
+// ShouldOffer implements OfferPolicy.
+func (s *OfferScheduler) ShouldOffer(node *enode.Node, contentId []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := node.ID()
+	key := string(contentId)
+	now := time.Now()
+
+	if until, ok := s.backoffUntil[id]; ok && now.Before(until) {
+		return false
+	}
+	if !expired(s.retryAfter[id], key, now) {
+		return false
+	}
+	if _, ok := s.alreadyStored[id][key]; ok {
+		return false
+	}
+	if !expired(s.outOfRadius[id], key, now) {
+		return false
+	}
+	return s.bucket(id).take()
+}
+
+// expired reports whether key's deadline in m has passed (or was never
+// set), deleting the entry either way so retryAfter/outOfRadius don't
+// grow without bound for peers and content ids that are never
+// reconsidered. Safe to call with a nil m.
+func expired(m map[string]time.Time, key string, now time.Time) bool {
+	until, ok := m[key]
+	if !ok {
+		return true
+	}
+	if now.Before(until) {
+		return false
+	}
+	delete(m, key)
+	return true
+}
+
+// OnResult implements OfferPolicy.
+func (s *OfferScheduler) OnResult(node *enode.Node, contentId []byte, code AcceptCode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := node.ID()
+	key := string(contentId)
+
+	switch code {
+	case Accepted:
+		s.metrics.accepted.Inc(1)
+		// A successful accept is evidence of spare capacity; let the
+		// backoff decay immediately rather than waiting it out.
+		delete(s.backoffUntil, id)
+		delete(s.backoffDuration, id)
+	case RateLimited:
+		s.metrics.rateLimited.Inc(1)
+		d := s.backoffDuration[id]
+		if d == 0 {
+			d = initialBackoff
+		} else {
+			d *= 2
+			if d > maxBackoff {
+				d = maxBackoff
+			}
+		}
+		s.backoffDuration[id] = d
+		s.backoffUntil[id] = time.Now().Add(d)
+	case InboundTransferInProgress:
+		s.metrics.inboundTransferInProgress.Inc(1)
+		retry, ok := s.retryAfter[id]
+		if !ok {
+			retry = make(map[string]time.Time)
+			s.retryAfter[id] = retry
+		}
+		retry[key] = time.Now().Add(inboundTransferRetry)
+	case AlreadyStored:
+		s.metrics.alreadyStored.Inc(1)
+		stored, ok := s.alreadyStored[id]
+		if !ok {
+			stored = make(map[string]struct{})
+			s.alreadyStored[id] = stored
+		}
+		stored[key] = struct{}{}
+	case NotWithinRadius:
+		s.metrics.notWithinRadius.Inc(1)
+		rejected, ok := s.outOfRadius[id]
+		if !ok {
+			rejected = make(map[string]time.Time)
+			s.outOfRadius[id] = rejected
+		}
+		rejected[key] = time.Now().Add(outOfRadiusTTL)
+	case GenericDeclined:
+		s.metrics.genericDeclined.Inc(1)
+	}
+}
+
+func (s *OfferScheduler) bucket(id enode.ID) *tokenBucket {
+	b, ok := s.buckets[id]
+	if !ok {
+		b = newTokenBucket(tokenBucketCapacity, tokenBucketRefillInterval)
+		s.buckets[id] = b
+	}
+	return b
+}
+
+// tokenBucket is a minimal token-bucket limiter: it starts full and
+// refills by one token every interval, up to capacity.
+type tokenBucket struct {
+	capacity int
+	interval time.Duration
+
+	tokens   int
+	lastFill time.Time
+}
+
+func newTokenBucket(capacity int, interval time.Duration) *tokenBucket {
+	return &tokenBucket{
+		capacity: capacity,
+		interval: interval,
+		tokens:   capacity,
+		lastFill: time.Now(),
+	}
+}
+
+func (b *tokenBucket) take() bool {
+	b.refill()
+	if b.tokens <= 0 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (b *tokenBucket) refill() {
+	elapsed := time.Since(b.lastFill)
+	if elapsed < b.interval {
+		return
+	}
+	add := int(elapsed / b.interval)
+	b.tokens += add
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.lastFill = b.lastFill.Add(time.Duration(add) * b.interval)
+}
@@ -86,8 +86,19 @@ func (a *AcceptV1) GetKeyLength() int {
 	return len(a.GetContentKeys())
 }
 
+// SetOfferSizeGuard installs guard as the check filterContentKeysV1 runs
+// before accepting an inbound offer: an offer whose content key makes
+// guard return true is declined with GenericDeclined instead of being
+// accepted into a transfer it can't legitimately complete (for example a
+// beacon LightClientUpdate offer spanning more periods than the spec's
+// per-request cap).
+func (p *PortalProtocol) SetOfferSizeGuard(guard func(contentKey []byte) bool) {
+	p.offerSizeGuard = guard
+}
+
 func (p *PortalProtocol) getOrStoreHighestVersion(node *enode.Node) (uint8, error) {
-	hcVersionValue, ok := p.versionsCache.Get(node)
+	cache := p.versionsCacheOrInit()
+	hcVersionValue, ok := cache.Get(node)
 	if ok {
 		return hcVersionValue, nil
 	}
@@ -96,7 +107,7 @@ func (p *PortalProtocol) getOrStoreHighestVersion(node *enode.Node) (uint8, erro
 	err := node.Load(versions)
 	// key is not set, return the default version
 	if enr.IsNotFound(err) {
-		p.versionsCache.Set(node, p.currentVersions[0], 0)
+		cache.Set(node, p.currentVersions[0], 0)
 		return p.currentVersions[0], nil
 	}
 	if err != nil {
@@ -104,7 +115,7 @@ func (p *PortalProtocol) getOrStoreHighestVersion(node *enode.Node) (uint8, erro
 	}
 
 	hcVersion, err := findBiggestSameNumber(p.currentVersions, *versions)
-	p.versionsCache.Set(node, hcVersion, 0)
+	cache.Set(node, hcVersion, 0)
 	return hcVersion, err
 }
 
@@ -154,6 +165,14 @@ func (p *PortalProtocol) filterContentKeysV1(request *Offer) (CommonAccept, [][]
 		if contentId == nil {
 			return nil, nil, ErrNilContentKey
 		}
+		if p.offerSizeGuard != nil && p.offerSizeGuard(contentKey) {
+			// e.g. a beacon LightClientUpdate offer spanning more
+			// periods than MaxRequestLightClientUpdates: reject
+			// outright rather than accept an offer we can't
+			// legitimately transfer.
+			acceptV1.ContentKeys[i] = uint8(GenericDeclined)
+			continue
+		}
 		if !inRange(p.Self().ID(), p.Radius(), contentId) {
 			acceptV1.ContentKeys[i] = uint8(NotWithinRadius)
 			continue
@@ -163,7 +182,7 @@ func (p *PortalProtocol) filterContentKeysV1(request *Offer) (CommonAccept, [][]
 			acceptV1.ContentKeys[i] = uint8(AlreadyStored)
 			continue
 		}
-		if exist := p.transferringKeyCache.Has(contentKey); exist {
+		if exist := p.transferringKeyCacheOrInit().Has(contentKey); exist {
 			acceptV1.ContentKeys[i] = uint8(InboundTransferInProgress)
 			continue
 		}
@@ -174,40 +193,100 @@ func (p *PortalProtocol) filterContentKeysV1(request *Offer) (CommonAccept, [][]
 }
 
 func (p *PortalProtocol) cacheTransferringKeys(contentKeys [][]byte) {
+	cache := p.transferringKeyCacheOrInit()
 	for _, key := range contentKeys {
-		p.transferringKeyCache.Set(key, EmptyBytes)
+		cache.Set(key, EmptyBytes)
 	}
 }
 
 func (p *PortalProtocol) deleteTransferringContentKeys(contentKeys [][]byte) {
+	cache := p.transferringKeyCacheOrInit()
 	for _, key := range contentKeys {
-		p.transferringKeyCache.Del(key)
+		cache.Del(key)
 	}
 }
 
-func (p *PortalProtocol) parseOfferResp(node *enode.Node, data []byte) (CommonAccept, error) {
+// parseOfferResp decodes a peer's Accept response and feeds it back into
+// the OfferScheduler via recordOfferResult. contentKeys must be the same
+// slice (and order) that was sent in the Offer this accept answers, so
+// the scheduler can line each AcceptV1 reason code up with the content
+// id it was about.
+func (p *PortalProtocol) parseOfferResp(node *enode.Node, contentKeys [][]byte, data []byte) (CommonAccept, error) {
 	version, err := p.getOrStoreHighestVersion(node)
 	if err != nil {
 		return nil, err
 	}
+	var accept CommonAccept
 	switch version {
 	case 0:
-		accept := &Accept{}
-		err = accept.UnmarshalSSZ(data)
-		if err != nil {
-			return nil, err
-		}
-		return accept, nil
+		accept = &Accept{}
 	case 1:
-		accept := &AcceptV1{}
-		err = accept.UnmarshalSSZ(data)
-		if err != nil {
-			return nil, err
-		}
-		return accept, nil
+		accept = &AcceptV1{}
 	default:
 		return nil, ErrUnsupportedVersion
 	}
+	if err := accept.UnmarshalSSZ(data); err != nil {
+		return nil, err
+	}
+	p.recordOfferResult(node, contentKeys, accept)
+	return accept, nil
+}
+
+// recordOfferResult feeds a peer's Accept response back into the
+// OfferScheduler so subsequent offers adapt to the granular AcceptV1
+// reason codes (RateLimited, InboundTransferInProgress, AlreadyStored,
+// NotWithinRadius) instead of treating every non-accept the same way.
+// contentKeys must be the same slice (and order) that was sent in the
+// Offer this accept answers.
+func (p *PortalProtocol) recordOfferResult(node *enode.Node, contentKeys [][]byte, accept CommonAccept) {
+	acceptV1, ok := accept.(*AcceptV1)
+	if !ok {
+		// Version 0 only distinguishes accepted/not-accepted; there is
+		// nothing granular to feed back to the scheduler.
+		return
+	}
+	scheduler := p.offerSchedulerOrInit()
+	for i, code := range acceptV1.ContentKeys {
+		if i >= len(contentKeys) {
+			break
+		}
+		contentId := p.toContentId(contentKeys[i])
+		scheduler.OnResult(node, contentId, AcceptCode(code))
+	}
+}
+
+// offerSchedulerOrInit returns p's adaptive OfferScheduler, creating one
+// on first use. The scheduler is lazily initialized rather than built in
+// a constructor so this package's offer path works regardless of which
+// PortalProtocol constructor a given deployment uses.
+func (p *PortalProtocol) offerSchedulerOrInit() *OfferScheduler {
+	if p.offerScheduler == nil {
+		p.offerScheduler = NewOfferScheduler()
+	}
+	return p.offerScheduler
+}
+
+// SelectOfferContentKeys filters candidateKeys down to the ones the
+// OfferScheduler currently thinks are worth offering to node, given
+// everything learned from past Accept responses (backoff, per-peer
+// retry on InboundTransferInProgress, AlreadyStored suppression,
+// NotWithinRadius suppression, and the peer's token bucket). Callers
+// that build an Offer should run their candidate content keys through
+// this before sending it; the resulting Accept should then be run
+// through parseOfferResp so the scheduler learns from it in turn.
+func (p *PortalProtocol) SelectOfferContentKeys(node *enode.Node, candidateKeys [][]byte) [][]byte {
+	scheduler := p.offerSchedulerOrInit()
+	selected := make([][]byte, 0, len(candidateKeys))
+	for _, contentKey := range candidateKeys {
+		contentId := p.toContentId(contentKey)
+		if contentId == nil {
+			continue
+		}
+		if scheduler.ShouldOffer(node, contentId) {
+			selected = append(selected, contentKey)
+		}
+	}
+	return selected
 }
 
 // findTheBiggestSameNumber finds the largest value that exists in both slices.
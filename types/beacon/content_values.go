@@ -0,0 +1,40 @@
+package beacon
+
+import "github.com/protolambda/zrnt/eth2/beacon/common"
+
+// ContentValue is implemented by each Forked* content-value wrapper
+// (ForkedLightClientBootstrap, ForkedLightClientFinalityUpdate, ...) so
+// that callers can decode and unwrap a beacon content value without
+// knowing which fork-specific field holds the payload.
+type ContentValue interface {
+	Unwrap() common.SpecObj
+}
+
+// Unwrap implements ContentValue.
+func (f *ForkedLightClientBootstrap) Unwrap() common.SpecObj {
+	return f.Bootstrap
+}
+
+// Unwrap implements ContentValue.
+func (f *ForkedLightClientFinalityUpdate) Unwrap() common.SpecObj {
+	return f.LightClientFinalityUpdate
+}
+
+// Unwrap implements ContentValue.
+func (f *ForkedLightClientOptimisticUpdate) Unwrap() common.SpecObj {
+	return f.LightClientOptimisticUpdate
+}
+
+// Unwrap implements ContentValue.
+func (f *ForkedLightClientUpdate) Unwrap() common.SpecObj {
+	return f.LightClientUpdate
+}
+
+// UnwrapAll unwraps every update in the range, in order.
+func (r LightClientUpdateRange) UnwrapAll() []common.SpecObj {
+	res := make([]common.SpecObj, len(r))
+	for i, item := range r {
+		res[i] = item.Unwrap()
+	}
+	return res
+}